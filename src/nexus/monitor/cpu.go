@@ -0,0 +1,21 @@
+package monitor
+
+import (
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/wandb/wandb/nexus/pkg/observability"
+	"github.com/wandb/wandb/nexus/service"
+)
+
+func sampleCPU(stats *service.StatsRecord, logger *observability.Logger) {
+	percent, err := cpu.Percent(0, false)
+	if err != nil {
+		logger.Debug("MONITOR: failed to sample cpu", "error", err)
+		return
+	}
+	if len(percent) > 0 {
+		stats.Item = append(stats.Item, &service.StatsItem{
+			Key:       "cpu",
+			ValueJson: jsonNumber(percent[0]),
+		})
+	}
+}
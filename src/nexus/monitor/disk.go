@@ -0,0 +1,19 @@
+package monitor
+
+import (
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/wandb/wandb/nexus/pkg/observability"
+	"github.com/wandb/wandb/nexus/service"
+)
+
+func sampleDisk(stats *service.StatsRecord, logger *observability.Logger) {
+	usage, err := disk.Usage("/")
+	if err != nil {
+		logger.Debug("MONITOR: failed to sample disk", "error", err)
+		return
+	}
+	stats.Item = append(stats.Item, &service.StatsItem{
+		Key:       "disk_percent",
+		ValueJson: jsonNumber(usage.UsedPercent),
+	})
+}
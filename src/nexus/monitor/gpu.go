@@ -0,0 +1,9 @@
+package monitor
+
+import "github.com/wandb/wandb/nexus/service"
+
+// sampleGPU adds no GPU stats, whether or not an NVIDIA device is present.
+// TODO: shell out to nvidia-smi (or bind NVML) once GPU hosts are in scope;
+// until then this is a no-op so CPU-only runs aren't penalized.
+func sampleGPU(stats *service.StatsRecord) {
+}
@@ -0,0 +1,19 @@
+package monitor
+
+import (
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/wandb/wandb/nexus/pkg/observability"
+	"github.com/wandb/wandb/nexus/service"
+)
+
+func sampleMemory(stats *service.StatsRecord, logger *observability.Logger) {
+	vm, err := mem.VirtualMemory()
+	if err != nil {
+		logger.Debug("MONITOR: failed to sample memory", "error", err)
+		return
+	}
+	stats.Item = append(stats.Item, &service.StatsItem{
+		Key:       "memory_percent",
+		ValueJson: jsonNumber(vm.UsedPercent),
+	})
+}
@@ -0,0 +1,105 @@
+// Package monitor samples host resource utilization (CPU, memory, disk,
+// and network; GPU utilization is not sampled) on a fixed interval and turns each
+// sample into a service.Record so it can flow through the normal
+// Handler pipeline alongside history and summary records.
+package monitor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wandb/wandb/nexus/pkg/observability"
+	"github.com/wandb/wandb/nexus/service"
+)
+
+const defaultSamplingInterval = 10 * time.Second
+
+// SystemMonitor periodically samples system stats and feeds a
+// Record_Stats back into the handler's pipeline via outChan, the same
+// channel Handler uses to receive records from its client. Samples are
+// timestamped relative to startTime so they line up with the run's
+// history/summary records.
+type SystemMonitor struct {
+	outChan  chan service.Record
+	interval time.Duration
+	enabled  bool
+	logger   *observability.Logger
+
+	startTime float64
+
+	doneChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func New(outChan chan service.Record, startTime float64, interval time.Duration, enabled bool, logger *observability.Logger) *SystemMonitor {
+	if interval <= 0 {
+		interval = defaultSamplingInterval
+	}
+	return &SystemMonitor{
+		outChan:   outChan,
+		interval:  interval,
+		enabled:   enabled,
+		logger:    logger,
+		startTime: startTime,
+		doneChan:  make(chan struct{}),
+	}
+}
+
+func (m *SystemMonitor) Start() {
+	if !m.enabled {
+		return
+	}
+	m.wg.Add(1)
+	go m.loop()
+}
+
+func (m *SystemMonitor) Stop() {
+	if !m.enabled {
+		return
+	}
+	close(m.doneChan)
+	m.wg.Wait()
+}
+
+func (m *SystemMonitor) loop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.doneChan:
+			return
+		case <-ticker.C:
+			m.sample()
+		}
+	}
+}
+
+// sample collects one snapshot of host stats and hands it to the
+// pipeline. It never blocks the caller for long: a full outChan drops
+// the sample rather than stalling the sampling goroutine.
+func (m *SystemMonitor) sample() {
+	item := collect(m.logger)
+	item.Timestamp = float64(time.Now().UnixMicro())/1e6 - m.startTime
+
+	rec := service.Record{
+		RecordType: &service.Record_Stats{Stats: item},
+	}
+
+	select {
+	case m.outChan <- rec:
+	default:
+		m.logger.Debug("MONITOR: outChan full, dropping sample")
+	}
+}
+
+func collect(logger *observability.Logger) *service.StatsRecord {
+	stats := &service.StatsRecord{}
+	sampleCPU(stats, logger)
+	sampleMemory(stats, logger)
+	sampleDisk(stats, logger)
+	sampleNetwork(stats, logger)
+	sampleGPU(stats)
+	return stats
+}
@@ -0,0 +1,21 @@
+package monitor
+
+import (
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/wandb/wandb/nexus/pkg/observability"
+	"github.com/wandb/wandb/nexus/service"
+)
+
+func sampleNetwork(stats *service.StatsRecord, logger *observability.Logger) {
+	counters, err := net.IOCounters(false)
+	if err != nil || len(counters) == 0 {
+		if err != nil {
+			logger.Debug("MONITOR: failed to sample network", "error", err)
+		}
+		return
+	}
+	stats.Item = append(stats.Item,
+		&service.StatsItem{Key: "network_sent", ValueJson: jsonNumber(float64(counters[0].BytesSent))},
+		&service.StatsItem{Key: "network_recv", ValueJson: jsonNumber(float64(counters[0].BytesRecv))},
+	)
+}
@@ -0,0 +1,9 @@
+package monitor
+
+import "strconv"
+
+// jsonNumber renders a float64 as the bare JSON number StatsItem.ValueJson
+// expects (the field holds a JSON-encoded scalar, not a Go value).
+func jsonNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,21 @@
+package observability
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// ParseLevel maps the string a user sets via Settings to a slog.Level,
+// defaulting to Info for anything unrecognized rather than failing.
+func ParseLevel(s string) slog.Level {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,55 @@
+// Package observability provides the structured, leveled logger used
+// across nexus/server, replacing the package-global logrus logger with
+// one that can be scoped per run and written to a per-run log file.
+package observability
+
+import (
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Params configures a Logger. LogFile is optional: when empty, logs go
+// to stderr only.
+type Params struct {
+	LogFile string
+	Level   slog.Level
+	JSON    bool
+}
+
+// Logger wraps slog.Logger with the helpers nexus/server needs to tag
+// log lines with run identity and record type.
+type Logger struct {
+	*slog.Logger
+}
+
+func New(params Params) *Logger {
+	var w io.Writer = os.Stderr
+	if params.LogFile != "" {
+		if f, err := os.OpenFile(params.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+			w = io.MultiWriter(os.Stderr, f)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: params.Level}
+	var handler slog.Handler
+	if params.JSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return &Logger{Logger: slog.New(handler)}
+}
+
+// WithRun returns a child logger with this run's identity attached, so
+// every line logged through it carries entity/project/run_id without
+// repeating them at each call site.
+func (l *Logger) WithRun(entity, project, runID string) *Logger {
+	return &Logger{Logger: l.Logger.With("entity", entity, "project", project, "run_id", runID)}
+}
+
+// WithRecordType returns a child logger tagged with the record type
+// being processed (e.g. "history", "stats", "files").
+func (l *Logger) WithRecordType(recordType string) *Logger {
+	return &Logger{Logger: l.Logger.With("record_type", recordType)}
+}
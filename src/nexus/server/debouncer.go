@@ -0,0 +1,119 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wandb/wandb/nexus/service"
+	"golang.org/x/time/rate"
+)
+
+// DebounceMetrics tracks how a Debouncer is behaving so it can be
+// surfaced on heartbeats or in logs when a run is producing updates
+// faster than the rate limit allows.
+type DebounceMetrics struct {
+	// Merged counts updates that arrived while a previous, not-yet-flushed
+	// update was still pending and were coalesced into it. Nothing is
+	// lost to the caller (the latest value always ships), but the
+	// intermediate value never goes out on its own.
+	Merged int64
+	// Throttled counts updates that couldn't flush immediately because
+	// the rate limiter had no token available, and were instead left
+	// pending for the scheduled trailing flush.
+	Throttled int64
+}
+
+// Debouncer coalesces a rapid stream of updates (partial history,
+// summary) into the most recent value and forwards at most one record
+// per token-bucket tick, so Sender/FileStream don't see every single
+// update hit the network. A throttled update is never stranded: a
+// single fixed-interval timer guarantees it flushes on the next tick
+// even under sustained load, instead of waiting for updates to pause or
+// for handleRunExit to push it out.
+type Debouncer struct {
+	limiter *rate.Limiter
+	period  time.Duration
+	onFlush func(*service.Record)
+
+	mu      sync.Mutex
+	pending *service.Record
+	timer   *time.Timer
+	metrics DebounceMetrics
+}
+
+// NewDebouncer builds a Debouncer that calls onFlush with the coalesced
+// record whenever a burst is flushed, immediately or on a later tick.
+func NewDebouncer(r rate.Limit, burst int, onFlush func(*service.Record)) *Debouncer {
+	var period time.Duration
+	if r > 0 {
+		period = time.Duration(float64(time.Second) / float64(r))
+	}
+	return &Debouncer{
+		limiter: rate.NewLimiter(r, burst),
+		period:  period,
+		onFlush: onFlush,
+	}
+}
+
+// Debounce absorbs rec into the pending update. If the limiter has a
+// token available the pending update is flushed immediately; otherwise
+// it is merged into the next flush. A throttled update schedules exactly
+// one trailing-flush timer for period from now -- it is never reset by
+// later calls, so a continuous stream of updates still gets forwarded
+// at most once per tick instead of starving the timer forever.
+func (d *Debouncer) Debounce(rec *service.Record) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.pending != nil {
+		d.metrics.Merged++
+	}
+	d.pending = rec
+
+	if d.limiter.Allow() {
+		d.flushLocked()
+		return
+	}
+
+	d.metrics.Throttled++
+	if d.timer == nil && d.period > 0 {
+		d.timer = time.AfterFunc(d.period, d.trailingFlush)
+	}
+}
+
+// trailingFlush is the scheduled callback that ships a throttled update
+// once its tick arrives, even if nothing else triggers a flush in the
+// meantime.
+func (d *Debouncer) trailingFlush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.timer = nil
+	d.flushLocked()
+}
+
+// Flush forwards the pending update, if any, regardless of rate limit.
+// Callers use this on handleRunExit and on explicit flush requests so
+// no update is lost at a debounce boundary.
+func (d *Debouncer) Flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.flushLocked()
+}
+
+func (d *Debouncer) flushLocked() {
+	if d.pending == nil {
+		return
+	}
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.onFlush(d.pending)
+	d.pending = nil
+}
+
+func (d *Debouncer) Metrics() DebounceMetrics {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.metrics
+}
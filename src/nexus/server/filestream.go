@@ -0,0 +1,38 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/wandb/wandb/nexus/pkg/observability"
+	"github.com/wandb/wandb/nexus/service"
+)
+
+// FileStream receives history, summary, and system metrics records as
+// the run progresses. It holds the backend's file_stream endpoint in
+// path but makes no network call to it.
+type FileStream struct {
+	wg     *sync.WaitGroup
+	path   string
+	logger *observability.Logger
+
+	settings *Settings
+}
+
+func NewFileStream(wg *sync.WaitGroup, path string, settings *Settings, logger *observability.Logger) *FileStream {
+	return &FileStream{
+		wg:       wg,
+		path:     path,
+		settings: settings,
+		logger:   logger,
+	}
+}
+
+func (fs *FileStream) Stop() {
+}
+
+// StreamRecord takes a record (history, summary, stats, or a captured
+// output.log line) and logs it at debug level; nothing is sent to the
+// backend.
+func (fs *FileStream) StreamRecord(rec *service.Record) {
+	fs.logger.Debug("FILESTREAM: streaming record", "rec", rec)
+}
@@ -1,83 +1,227 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"github.com/wandb/wandb/nexus/service"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/wandb/wandb/nexus/monitor"
+	"github.com/wandb/wandb/nexus/pkg/observability"
+	"github.com/wandb/wandb/nexus/watcher"
+
+	"golang.org/x/time/rate"
 )
 
+// systemMonitor is the interface Handler needs from whatever stage is
+// plugged in ahead of it; it lets WithHandlerSystemMonitor accept any
+// implementation. When none is given, handleRunStart builds a
+// monitor.SystemMonitor from Settings.
+type systemMonitor interface {
+	Start()
+	Stop()
+}
+
 type Handler struct {
-	handlerChan chan service.Record
+	// inChan is fed by HandleRecord; fwdChan and outChan are the two
+	// pipeline legs leading into Writer (durable log) and Sender
+	// (backend) respectively, replacing direct method calls so that
+	// extra stages (monitor, debouncer, dispatcher) can be spliced in
+	// later without touching Handler's call sites.
+	inChan  chan service.Record
+	fwdChan chan service.Record
+	outChan chan service.Record
 
 	currentStep int64
 	startTime   float64
+	summary     *service.SummaryRecord
 
 	wg      *sync.WaitGroup
 	writer  *Writer
 	sender  *Sender
 	fstream *FileStream
+	watcher *watcher.Watcher
 	run     service.RunRecord
 
+	// outputLog is output.log, the file Record_OutputRaw lines are
+	// appended to as they arrive.
+	outputLog *os.File
+
 	settings      *Settings
 	respondResult func(result *service.Result)
+	logger        *observability.Logger
+
+	systemMonitor systemMonitor
+
+	// historyDebouncer/summaryDebouncer coalesce rapid partial
+	// history/summary updates so Sender/FileStream see at most one
+	// flush per rate-limit tick instead of one per update.
+	historyDebouncer *Debouncer
+	summaryDebouncer *Debouncer
+
+	// ctx/cancel make handlerGo's loop shutdown-aware: Stop cancels ctx
+	// instead of relying on closing inChan, which would race with
+	// in-flight HandleRecord sends.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	status      int32 // ServerStatus, accessed atomically
+	heartbeater *heartbeater
 }
 
-func NewHandler(respondResult func(result *service.Result), settings *Settings) *Handler {
+type HandlerOption func(*Handler)
+
+func WithHandlerSettings(settings *Settings) HandlerOption {
+	return func(h *Handler) { h.settings = settings }
+}
+
+func WithHandlerFwdChannel(fwdChan chan service.Record) HandlerOption {
+	return func(h *Handler) { h.fwdChan = fwdChan }
+}
+
+func WithHandlerOutChannel(outChan chan service.Record) HandlerOption {
+	return func(h *Handler) { h.outChan = outChan }
+}
+
+func WithHandlerSystemMonitor(monitor systemMonitor) HandlerOption {
+	return func(h *Handler) { h.systemMonitor = monitor }
+}
+
+func NewHandler(respondResult func(result *service.Result), opts ...HandlerOption) *Handler {
 	wg := sync.WaitGroup{}
-	writer := NewWriter(&wg, settings)
-	sender := NewSender(&wg, respondResult, settings)
-	handler := Handler{
+	ctx, cancel := context.WithCancel(context.Background())
+	handler := &Handler{
 		wg:            &wg,
-		writer:        writer,
-		sender:        sender,
 		respondResult: respondResult,
-		settings:      settings,
-		handlerChan:   make(chan service.Record)}
+		inChan:        make(chan service.Record),
+		fwdChan:       make(chan service.Record),
+		outChan:       make(chan service.Record),
+		ctx:           ctx,
+		cancel:        cancel,
+		status:        int32(StatusNew),
+	}
+
+	for _, opt := range opts {
+		opt(handler)
+	}
+
+	handler.logger = observability.New(observability.Params{
+		LogFile: handler.settings.LogFile,
+		Level:   observability.ParseLevel(handler.settings.LogLevel),
+		JSON:    handler.settings.LogJSON,
+	})
+
+	handler.writer = NewWriter(handler.wg, handler.settings, handler.logger, handler.fwdChan)
+	handler.sender = NewSender(handler.wg, respondResult, handler.settings, handler.logger, handler.outChan)
+
+	debounceRate := rate.Limit(handler.settings.DebounceRate)
+	handler.historyDebouncer = NewDebouncer(debounceRate, handler.settings.DebounceBurst, handler.flushHistory)
+	handler.summaryDebouncer = NewDebouncer(debounceRate, handler.settings.DebounceBurst, handler.flushSummary)
+
+	handler.heartbeater = newHeartbeater(handler.settings, handler.logger, handler.Status, handler.queueDepths)
+	handler.heartbeater.Start(handler.ctx, handler.wg)
 
 	go handler.handlerGo()
-	return &handler
+	return handler
+}
+
+// Status reports the Handler's current lifecycle state.
+func (h *Handler) Status() ServerStatus {
+	return ServerStatus(atomic.LoadInt32(&h.status))
+}
+
+func (h *Handler) setStatus(status ServerStatus) {
+	atomic.StoreInt32(&h.status, int32(status))
+}
+
+func (h *Handler) queueDepths() map[string]int {
+	return map[string]int{
+		"in":  len(h.inChan),
+		"fwd": len(h.fwdChan),
+		"out": len(h.outChan),
+	}
 }
 
 func (handler *Handler) Stop() {
-	close(handler.handlerChan)
+	handler.cancel()
 }
 
 func (h *Handler) startRunWorkers() {
 	fsPath := fmt.Sprintf("%s/files/%s/%s/%s/file_stream",
 		h.settings.BaseURL, h.run.Entity, h.run.Project, h.run.RunId)
-	h.fstream = NewFileStream(h.wg, fsPath, h.settings)
+	h.fstream = NewFileStream(h.wg, fsPath, h.settings, h.logger)
+
+	fileWatcher, err := watcher.New(h.settings.FilesDir, h.outChan, h.logger)
+	if err != nil {
+		h.logger.Error("HANDLER: failed to start file watcher", "error", err)
+		return
+	}
+	h.watcher = fileWatcher
+	h.watcher.Add("config.yaml", watcher.PolicyEnd)
+	h.watcher.Add("wandb-metadata.json", watcher.PolicyNow)
+	h.watcher.Add("requirements.txt", watcher.PolicyNow)
+	h.watcher.Add("diff.patch", watcher.PolicyNow)
+	h.watcher.Add("output.log", watcher.PolicyLive)
+	h.watcher.Start()
+
+	outputLog, err := os.OpenFile(filepath.Join(h.settings.FilesDir, "output.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		h.logger.Error("HANDLER: failed to open output.log", "error", err)
+		return
+	}
+	h.outputLog = outputLog
 }
 
 func (handler *Handler) HandleRecord(rec *service.Record) {
-	handler.handlerChan <- *rec
+	handler.inChan <- *rec
 }
 
 func (h *Handler) shutdownStream() {
-	h.writer.Stop()
-	h.sender.Stop()
+	// Cancel ctx before waiting: the heartbeater only returns on
+	// ctx.Done(), and nothing else cancels it on a normal run exit, so
+	// skipping this would make wg.Wait() block forever.
+	h.cancel()
+	// Handler is the producer of fwdChan/outChan, so it's the one that
+	// closes them -- Writer/Sender only consume, and a consumer closing
+	// a channel it doesn't own risks a send-on-closed-channel panic if
+	// the producer hasn't actually stopped sending yet. handlerGo is
+	// guaranteed to have stopped sending into either channel by the time
+	// shutdownStream returns (see handlerGo).
+	close(h.fwdChan)
+	close(h.outChan)
 	if h.fstream != nil {
 		h.fstream.Stop()
 	}
+	if h.outputLog != nil {
+		h.outputLog.Close()
+	}
 	h.wg.Wait()
 }
 
 func (h *Handler) captureRunInfo(run *service.RunRecord) {
 	h.startTime = float64(run.StartTime.AsTime().UnixMicro()) / 1e6
 	h.run = *run
+	h.logger = h.logger.WithRun(run.Entity, run.Project, run.RunId)
 }
 
 func (h *Handler) handleRunStart(rec *service.Record, req *service.RunStartRequest) {
 	h.captureRunInfo(req.Run)
 	h.startRunWorkers()
+	if h.systemMonitor == nil {
+		h.systemMonitor = monitor.New(h.inChan, h.startTime, h.settings.SystemMonitorInterval, h.settings.SystemMonitorEnabled, h.logger)
+	}
+	h.systemMonitor.Start()
+	h.setStatus(StatusActive)
 }
 
 func (h *Handler) handleRun(rec *service.Record, run *service.RunRecord) {
 	// runResult := &service.RunUpdateResult{Run: run}
 
 	// let sender take care of it
-	h.sender.SendRecord(rec)
+	h.outChan <- *rec
 
 	/*
 	   result := &service.Result{
@@ -90,7 +234,19 @@ func (h *Handler) handleRun(rec *service.Record, run *service.RunRecord) {
 }
 
 func (h *Handler) handleRunExit(rec *service.Record, runExit *service.RunExitRecord) {
-	// TODO: need to flush stuff before responding with exit
+	h.setStatus(StatusFlushing)
+	if h.systemMonitor != nil {
+		h.systemMonitor.Stop()
+	}
+	h.historyDebouncer.Flush()
+	h.summaryDebouncer.Flush()
+	if h.watcher != nil {
+		h.watcher.Stop()
+	}
+	// Don't tell the client the run is done until every file we know
+	// about has finished uploading.
+	h.sender.DrainUploads()
+
 	runExitResult := &service.RunExitResult{}
 	result := &service.Result{
 		ResultType: &service.Result_ExitResult{runExitResult},
@@ -99,21 +255,131 @@ func (h *Handler) handleRunExit(rec *service.Record, runExit *service.RunExitRec
 	}
 	h.respondResult(result)
 	h.shutdownStream()
+	h.setStatus(StatusStopped)
+}
+
+func (h *Handler) handlePartialHistory(rec *service.Record, req *service.PartialHistoryRequest) {
+	history := &service.Record{
+		RecordType: &service.Record_History{History: &service.HistoryRecord{
+			Item: req.Item,
+			Step: req.Step,
+		}},
+		Control: rec.Control,
+		Uuid:    rec.Uuid,
+	}
+
+	h.historyDebouncer.Debounce(history)
+	if req.Action != nil && req.Action.Flush {
+		h.historyDebouncer.Flush()
+	}
+}
+
+// flushHistory is historyDebouncer's onFlush callback. The synthesized
+// History record never goes through storeRecord (it's built out of a
+// Record_Request, which storeRecord skips), so it has to be persisted
+// here as well or it would never make it into the transaction log.
+func (h *Handler) flushHistory(rec *service.Record) {
+	h.fwdChan <- *rec
+	h.outChan <- *rec
+	if h.fstream != nil {
+		h.fstream.StreamRecord(rec)
+	}
+}
+
+func (h *Handler) captureSummary(summary *service.SummaryRecord) {
+	h.summary = summary
+}
+
+func (h *Handler) handleSummary(rec *service.Record, summary *service.SummaryRecord) {
+	h.captureSummary(summary)
+	h.summaryDebouncer.Debounce(rec)
+}
+
+// flushSummary is summaryDebouncer's onFlush callback. Unlike history,
+// the raw Record_Summary already went through storeRecord when it
+// arrived, so this only needs to forward to Sender/FileStream.
+func (h *Handler) flushSummary(rec *service.Record) {
+	h.outChan <- *rec
+	if h.fstream != nil {
+		h.fstream.StreamRecord(rec)
+	}
+}
+
+// handleResume replays the existing transaction log from the client's
+// last confirmed offset so Sender/FileStream catch up on everything the
+// previous, interrupted process already wrote, then reconciles
+// currentStep/startTime/summary state before the run accepts new
+// records. It replays through h.writer rather than a separately opened
+// Reader: the Writer may already be appending to this same file (Resume
+// opens it O_APPEND), and only the Writer's recordStore holds the lock
+// that keeps a concurrent append from tearing the record currently being
+// read.
+func (h *Handler) handleResume(rec *service.Record, req *service.ResumeRequest) {
+	err := h.writer.Replay(req.FromOffset, func(old *service.Record) error {
+		switch x := old.RecordType.(type) {
+		case *service.Record_Run:
+			h.captureRunInfo(x.Run)
+		case *service.Record_History:
+			h.currentStep = x.History.GetStep().GetNum()
+		case *service.Record_Summary:
+			h.captureSummary(x.Summary)
+		}
+		h.outChan <- *old
+		switch old.RecordType.(type) {
+		case *service.Record_History, *service.Record_Summary, *service.Record_Stats, *service.Record_OutputRaw:
+			if h.fstream != nil {
+				h.fstream.StreamRecord(old)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		h.logger.Error("HANDLER: failed to replay transaction log for resume", "error", err)
+	}
+}
+
+func (h *Handler) handleStats(rec *service.Record, stats *service.StatsRecord) {
+	h.outChan <- *rec
+	if h.fstream != nil {
+		h.fstream.StreamRecord(rec)
+	}
+}
+
+func (h *Handler) handleFiles(rec *service.Record, files *service.FilesRecord) {
+	h.outChan <- *rec
+}
+
+// handleOutputRaw captures a line of the run's stdout/stderr: it is
+// appended to output.log (which the file watcher already tracks with
+// PolicyLive, so it gets uploaded like any other changed file) and
+// streamed to FileStream directly, instead of being silently dropped.
+func (h *Handler) handleOutputRaw(rec *service.Record, output *service.OutputRawRecord) {
+	if h.outputLog != nil {
+		if _, err := h.outputLog.WriteString(output.Line); err != nil {
+			h.logger.Error("HANDLER: failed to write to output.log", "error", err)
+		}
+	}
+	if h.fstream != nil {
+		h.fstream.StreamRecord(rec)
+	}
 }
 
 func (h *Handler) handleRequest(rec *service.Record, req *service.Request) {
 	ref := req.ProtoReflect()
 	desc := ref.Descriptor()
 	num := ref.WhichOneof(desc.Oneofs().ByName("request_type")).Number()
-	log.WithFields(log.Fields{"type": num}).Debug("PROCESS: REQUEST")
+	h.logger.Debug("PROCESS: REQUEST", "type", num)
 
 	switch x := req.RequestType.(type) {
 	case *service.Request_PartialHistory:
-		log.WithFields(log.Fields{"req": x}).Debug("PROCESS: got partial")
+		h.logger.Debug("PROCESS: got partial", "req", x)
 		h.handlePartialHistory(rec, x.PartialHistory)
 	case *service.Request_RunStart:
-		log.WithFields(log.Fields{"req": x}).Debug("PROCESS: got start")
+		h.logger.Debug("PROCESS: got start", "req", x)
 		h.handleRunStart(rec, x.RunStart)
+	case *service.Request_Resume:
+		h.logger.Debug("PROCESS: got resume", "req", x)
+		h.handleResume(rec, x.Resume)
 	default:
 	}
 
@@ -131,10 +397,10 @@ func (handler *Handler) handleRecord(msg *service.Record) {
 	case *service.Record_Header:
 		// fmt.Println("headgot:", x)
 	case *service.Record_Request:
-		log.WithFields(log.Fields{"req": x}).Debug("reqgot")
+		handler.logger.Debug("reqgot", "req", x)
 		handler.handleRequest(msg, x.Request)
 	case *service.Record_Summary:
-		// fmt.Println("sumgot:", x)
+		handler.handleSummary(msg, x.Summary)
 	case *service.Record_Run:
 		// fmt.Println("rungot:", x)
 		handler.handleRun(msg, x.Run)
@@ -143,7 +409,11 @@ func (handler *Handler) handleRecord(msg *service.Record) {
 	case *service.Record_Telemetry:
 		// fmt.Println("telgot:", x)
 	case *service.Record_OutputRaw:
-		// fmt.Println("outgot:", x)
+		handler.handleOutputRaw(msg, x.OutputRaw)
+	case *service.Record_Stats:
+		handler.handleStats(msg, x.Stats)
+	case *service.Record_Files:
+		handler.handleFiles(msg, x.Files)
 	case *service.Record_Exit:
 		// fmt.Println("exitgot:", x)
 		handler.handleRunExit(msg, x.Exit)
@@ -164,19 +434,31 @@ func (h *Handler) storeRecord(msg *service.Record) {
 		// The field is not set.
 		panic("bad3rec")
 	default:
-		h.writer.WriteRecord(msg)
+		h.fwdChan <- *msg
 	}
 }
 
 func (handler *Handler) handlerGo() {
-	log.Debug("HANDLER")
+	handler.logger.Debug("HANDLER")
 	for {
 		select {
-		case record := <-handler.handlerChan:
-			log.WithFields(log.Fields{"rec": record}).Debug("HANDLER")
+		case <-handler.ctx.Done():
+			handler.logger.Debug("HANDLER OUT")
+			return
+		case record := <-handler.inChan:
+			handler.logger.Debug("HANDLER", "rec", record)
 			handler.storeRecord(&record)
 			handler.handleRecord(&record)
+			// handleRunExit's shutdownStream cancels ctx and closes
+			// fwdChan/outChan. Return here instead of looping back to
+			// select: select would race ctx.Done() against a record that
+			// happens to arrive at the same instant, and processing one
+			// more record would send into the now-closed channels and
+			// panic.
+			if handler.ctx.Err() != nil {
+				handler.logger.Debug("HANDLER OUT")
+				return
+			}
 		}
 	}
-	log.Debug("HANDLER OUT")
-}
\ No newline at end of file
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+
+	"github.com/wandb/wandb/nexus/pkg/observability"
+)
+
+const defaultHeartbeatInterval = 30 * time.Second
+const heartbeatTimeout = 10 * time.Second
+
+// heartbeater periodically reports this run's liveness to the backend:
+// host, pid, a stable per-process server ID, and how deep the pipeline
+// queues are, so a supervisor can tell an active run from a stalled one.
+type heartbeater struct {
+	serverID    string
+	settings    *Settings
+	logger      *observability.Logger
+	status      func() ServerStatus
+	queueDepths func() map[string]int
+	interval    time.Duration
+	httpClient  *http.Client
+}
+
+func newHeartbeater(settings *Settings, logger *observability.Logger, status func() ServerStatus, queueDepths func() map[string]int) *heartbeater {
+	interval := settings.HeartbeatInterval
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	return &heartbeater{
+		serverID:    xid.New().String(),
+		settings:    settings,
+		logger:      logger,
+		status:      status,
+		queueDepths: queueDepths,
+		interval:    interval,
+		httpClient:  &http.Client{Timeout: heartbeatTimeout},
+	}
+}
+
+func (hb *heartbeater) Start(ctx context.Context, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go hb.loop(ctx, wg)
+}
+
+func (hb *heartbeater) loop(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ticker := time.NewTicker(hb.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hb.beat()
+		}
+	}
+}
+
+// heartbeatPayload is what beat POSTs to the backend's heartbeat
+// endpoint.
+type heartbeatPayload struct {
+	ServerID string         `json:"server_id"`
+	Host     string         `json:"host"`
+	Pid      int            `json:"pid"`
+	Status   string         `json:"status"`
+	Queues   map[string]int `json:"queues"`
+}
+
+func (hb *heartbeater) beat() {
+	host, err := os.Hostname()
+	if err != nil {
+		hb.logger.Debug("HEARTBEAT: failed to read hostname", "error", err)
+	}
+
+	payload := heartbeatPayload{
+		ServerID: hb.serverID,
+		Host:     host,
+		Pid:      os.Getpid(),
+		Status:   hb.status().String(),
+		Queues:   hb.queueDepths(),
+	}
+	hb.logger.Debug("HEARTBEAT: posting run status", "payload", payload)
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		hb.logger.Error("HEARTBEAT: failed to marshal run status", "error", err)
+		return
+	}
+
+	url := fmt.Sprintf("%s/runs/heartbeat", hb.settings.BaseURL)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		hb.logger.Error("HEARTBEAT: failed to build request", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hb.settings.APIKey != "" {
+		req.SetBasicAuth("api", hb.settings.APIKey)
+	}
+
+	resp, err := hb.httpClient.Do(req)
+	if err != nil {
+		hb.logger.Error("HEARTBEAT: failed to post run status", "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		hb.logger.Error("HEARTBEAT: backend rejected run status", "status", resp.StatusCode)
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/wandb/wandb/nexus/pkg/observability"
+	"github.com/wandb/wandb/nexus/service"
+)
+
+// Sender consumes the outbound leg of the pipeline: SendRecord hands
+// Record_Files to the uploader pool and logs every other record at
+// debug level. It makes no GraphQL or file_stream call and produces no
+// Result of its own.
+type Sender struct {
+	wg            *sync.WaitGroup
+	settings      *Settings
+	logger        *observability.Logger
+	inChan        chan service.Record
+	respondResult func(result *service.Result)
+
+	// barrierChan lets a caller (DrainUploads) wait for senderGo to have
+	// fully processed every record handed off before the barrier, rather
+	// than just received it. Without this, a record sent to inChan can
+	// return to its sender (the channel rendezvous completes) before
+	// SendRecord -- and thus uploader.Enqueue -- has actually run, so
+	// draining immediately afterwards could miss it.
+	barrierChan chan chan struct{}
+
+	uploader *uploader
+}
+
+func NewSender(wg *sync.WaitGroup, respondResult func(result *service.Result), settings *Settings, logger *observability.Logger, inChan chan service.Record) *Sender {
+	sender := &Sender{
+		wg:            wg,
+		settings:      settings,
+		logger:        logger,
+		inChan:        inChan,
+		respondResult: respondResult,
+		barrierChan:   make(chan chan struct{}),
+		uploader:      newUploader(defaultUploadWorkers, logger),
+	}
+
+	wg.Add(1)
+	go sender.senderGo()
+	return sender
+}
+
+func (s *Sender) SendRecord(rec *service.Record) {
+	if files, ok := rec.RecordType.(*service.Record_Files); ok {
+		s.sendFiles(files.Files)
+		return
+	}
+	s.logger.Debug("SENDER: sending record", "rec", rec)
+}
+
+func (s *Sender) sendFiles(files *service.FilesRecord) {
+	for _, f := range files.Files {
+		s.uploader.Enqueue(f.Path)
+	}
+}
+
+// Barrier blocks until every record sent to inChan before this call was
+// received has been fully processed by senderGo. It establishes the
+// happens-before edge DrainUploads needs: senderGo only picks up the
+// barrier once it has looped back to select, which it can't do until
+// the SendRecord call for the previous record (and any uploader.Enqueue
+// it made) has returned.
+func (s *Sender) Barrier() {
+	ack := make(chan struct{})
+	s.barrierChan <- ack
+	<-ack
+}
+
+// DrainUploads blocks until every file enqueued so far has finished
+// uploading. Handler calls this during handleRunExit before responding
+// with ExitResult, so the client doesn't see the run as done while
+// artifacts are still in flight.
+func (s *Sender) DrainUploads() {
+	s.Barrier()
+	s.uploader.Drain()
+}
+
+func (s *Sender) senderGo() {
+	defer s.wg.Done()
+	for {
+		select {
+		case rec, ok := <-s.inChan:
+			if !ok {
+				s.logger.Debug("SENDER OUT")
+				return
+			}
+			s.SendRecord(&rec)
+		case ack := <-s.barrierChan:
+			close(ack)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import "time"
+
+// Settings holds the run-scoped configuration that is threaded through
+// Handler, Writer, Sender, and FileStream. It is populated from the
+// service.Settings proto sent by the client at stream start.
+type Settings struct {
+	BaseURL string
+	APIKey  string
+
+	// SystemMonitorEnabled/SystemMonitorInterval control the Handler's
+	// SystemMonitor: whether it samples host stats at all, and how often.
+	SystemMonitorEnabled  bool
+	SystemMonitorInterval time.Duration
+
+	// DebounceRate/DebounceBurst configure the token bucket the history
+	// and summary debouncers use to coalesce rapid updates.
+	DebounceRate  float64
+	DebounceBurst int
+
+	// SyncFile is the path to the run's .wandb transaction log. Resume
+	// set via --resume tells Writer to append to it instead of
+	// truncating, so handleResume can replay what's already there.
+	SyncFile string
+	Resume   bool
+
+	// FilesDir is the run's files directory, watched for config.yaml,
+	// requirements.txt, output.log, diff.patch, wandb-metadata.json,
+	// and any user-added files.
+	FilesDir string
+
+	// LogLevel/LogFile/LogJSON configure the observability.Logger shared
+	// by Handler, Writer, Sender, and FileStream. LogFile is the per-run
+	// log file; an empty value means stderr only.
+	LogLevel string
+	LogFile  string
+	LogJSON  bool
+
+	// HeartbeatInterval controls how often the heartbeater POSTs this
+	// run's status to the backend. Zero means use the package default.
+	HeartbeatInterval time.Duration
+}
@@ -0,0 +1,28 @@
+package server
+
+// ServerStatus is the lifecycle state of a single run's Handler, so
+// external supervisors can tell a run apart from a stalled or already
+// finished one.
+type ServerStatus int32
+
+const (
+	StatusNew ServerStatus = iota
+	StatusActive
+	StatusFlushing
+	StatusStopped
+)
+
+func (s ServerStatus) String() string {
+	switch s {
+	case StatusNew:
+		return "new"
+	case StatusActive:
+		return "active"
+	case StatusFlushing:
+		return "flushing"
+	case StatusStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
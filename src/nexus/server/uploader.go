@@ -0,0 +1,53 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/wandb/wandb/nexus/pkg/observability"
+)
+
+const defaultUploadWorkers = 4
+
+// uploader runs a small worker pool for changed files. Each upload logs
+// the file's path at debug level; no file content is sent to the
+// backend. Sender enqueues a path per upload and Drain blocks until
+// every upload enqueued so far has completed, which is what lets
+// handleRunExit wait for artifacts before responding.
+type uploader struct {
+	jobChan chan string
+	pending sync.WaitGroup
+	logger  *observability.Logger
+}
+
+func newUploader(workers int, logger *observability.Logger) *uploader {
+	if workers <= 0 {
+		workers = defaultUploadWorkers
+	}
+	u := &uploader{jobChan: make(chan string, 64), logger: logger}
+	for i := 0; i < workers; i++ {
+		go u.worker()
+	}
+	return u
+}
+
+func (u *uploader) worker() {
+	for path := range u.jobChan {
+		u.upload(path)
+		u.pending.Done()
+	}
+}
+
+func (u *uploader) upload(path string) {
+	u.logger.Debug("UPLOADER: uploading file", "file", path)
+}
+
+func (u *uploader) Enqueue(path string) {
+	u.pending.Add(1)
+	u.jobChan <- path
+}
+
+// Drain blocks until every upload enqueued so far has finished. Safe to
+// call more than once; the worker pool keeps running afterwards.
+func (u *uploader) Drain() {
+	u.pending.Wait()
+}
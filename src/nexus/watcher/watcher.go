@@ -0,0 +1,123 @@
+// Package watcher watches a run's files directory and turns file
+// changes into service.Record_Files records, so Sender can pick them up
+// and upload them as run artifacts.
+package watcher
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/wandb/wandb/nexus/pkg/observability"
+	"github.com/wandb/wandb/nexus/service"
+)
+
+// Policy controls when a watched file is uploaded: Now uploads it once
+// as soon as it's registered, Live uploads on every change, and End
+// uploads only the final version, once the run is finishing up.
+type Policy int
+
+const (
+	PolicyNow Policy = iota
+	PolicyLive
+	PolicyEnd
+)
+
+type Watcher struct {
+	dir       string
+	outChan   chan service.Record
+	fsWatcher *fsnotify.Watcher
+	logger    *observability.Logger
+
+	files map[string]Policy
+
+	doneChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func New(dir string, outChan chan service.Record, logger *observability.Logger) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := fsWatcher.Add(dir); err != nil {
+		fsWatcher.Close()
+		return nil, err
+	}
+
+	return &Watcher{
+		dir:       dir,
+		outChan:   outChan,
+		fsWatcher: fsWatcher,
+		logger:    logger,
+		files:     make(map[string]Policy),
+		doneChan:  make(chan struct{}),
+	}, nil
+}
+
+// Add registers name (relative to dir) for tracking under policy. Now
+// files are emitted immediately.
+func (w *Watcher) Add(name string, policy Policy) {
+	w.files[name] = policy
+	if policy == PolicyNow {
+		w.emit(name)
+	}
+}
+
+func (w *Watcher) Start() {
+	w.wg.Add(1)
+	go w.loop()
+}
+
+// Stop shuts the watcher down, then emits any End-policy files one last
+// time. Callers should wait for the resulting uploads to drain
+// separately (Sender owns that).
+func (w *Watcher) Stop() {
+	close(w.doneChan)
+	w.wg.Wait()
+	w.fsWatcher.Close()
+
+	for name, policy := range w.files {
+		if policy == PolicyEnd {
+			w.emit(name)
+		}
+	}
+}
+
+func (w *Watcher) loop() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.doneChan:
+			return
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			name := filepath.Base(event.Name)
+			if policy, tracked := w.files[name]; tracked && policy == PolicyLive {
+				w.emit(name)
+			}
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.Debug("WATCHER: fsnotify error", "error", err)
+		}
+	}
+}
+
+// emit blocks until the record is handed off: unlike a stats sample, a
+// missed file-change event means an artifact never gets uploaded, so
+// there is no safe way to drop it.
+func (w *Watcher) emit(name string) {
+	rec := service.Record{
+		RecordType: &service.Record_Files{Files: &service.FilesRecord{
+			Files: []*service.FilesItem{{Path: name}},
+		}},
+	}
+	w.outChan <- rec
+}